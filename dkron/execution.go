@@ -0,0 +1,57 @@
+package dkron
+
+import (
+	"fmt"
+	"time"
+)
+
+// Execution represents a single run of a Job, dispatched to one or more
+// target nodes by a RunQuery.
+type Execution struct {
+	// Id uniquely identifies this execution, generated by NewExecution.
+	Id string `json:"id"`
+
+	// JobName is the name of the Job this execution belongs to.
+	JobName string `json:"job_name"`
+
+	// Command to run. Defaults to the job's Command; Trigger may override it.
+	Command string `json:"command"`
+
+	// EnvironmentVariables to give to the command. Defaults to the job's;
+	// Trigger may override them.
+	EnvironmentVariables []string `json:"environment_variables"`
+
+	// Tags of the target nodes to run this execution against. Defaults to
+	// the job's Tags; Trigger may override them.
+	Tags map[string]string `json:"tags"`
+
+	// Payload is made available to the executor for this run.
+	Payload []byte `json:"payload"`
+
+	// StartedAt is when the execution started running on a node.
+	StartedAt time.Time `json:"started_at"`
+
+	// FinishedAt is when the execution finished, zero while still running.
+	FinishedAt time.Time `json:"finished_at"`
+
+	// Success reports whether the execution completed without error.
+	Success bool `json:"success"`
+
+	// TimedOut reports whether the execution was killed by
+	// Job.enforceDeadline for exceeding ActiveDeadlineSeconds, as opposed to
+	// finishing (successfully or not) on its own.
+	TimedOut bool `json:"timed_out"`
+
+	// Output captured from the command.
+	Output []byte `json:"output"`
+}
+
+// NewExecution returns a new Execution for jobName, with a unique Id and
+// Command/EnvironmentVariables/Tags left empty for the caller (Job.Run or
+// Job.Trigger) to fill in from the job's own configuration.
+func NewExecution(jobName string) *Execution {
+	return &Execution{
+		Id:      fmt.Sprintf("%d-%s", time.Now().UnixNano(), jobName),
+		JobName: jobName,
+	}
+}
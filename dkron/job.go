@@ -1,8 +1,11 @@
 package dkron
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"math"
+	"math/rand"
 	"sync"
 	"time"
 
@@ -24,6 +27,28 @@ const (
 	ConcurrencyAllow = "allow"
 	// ConcurrencyForbid forbids a job from executing concurrency.
 	ConcurrencyForbid = "forbid"
+	// ConcurrencyReplace cancels any execution of the job already running
+	// before starting the new one.
+	ConcurrencyReplace = "replace"
+
+	// QueryJobPaused is the serf user event used to broadcast that a job was paused.
+	QueryJobPaused = "job:paused"
+	// QueryKillExecution is the serf query used to ask a node to cancel a
+	// running execution, used by the ConcurrencyReplace policy.
+	QueryKillExecution = "run:job:kill"
+
+	// RetryStrategyFixed retries after a constant RetryBackoff delay.
+	RetryStrategyFixed = "fixed"
+	// RetryStrategyExponential doubles the delay after each attempt, up to RetryBackoffMax.
+	RetryStrategyExponential = "exponential"
+
+	// replaceKillTimeout bounds how long isRunnable waits for a killed
+	// execution to report as finished before giving up and launching the
+	// replacement anyway.
+	replaceKillTimeout = 10 * time.Second
+	// replaceKillPollInterval is how often isRunnable polls the store while
+	// waiting for a killed execution to finish.
+	replaceKillPollInterval = 100 * time.Millisecond
 )
 
 var (
@@ -38,7 +63,17 @@ var (
 	// ErrNoCommand is returned when attempting to store a job that has no command.
 	ErrNoCommand = errors.New("Unespecified command for job")
 	// ErrWrongConcurrency is returned when Concurrency is set to a non existing setting.
-	ErrWrongConcurrency = errors.New("Wrong concurrency policy value, use: allow/forbid")
+	ErrWrongConcurrency = errors.New("Wrong concurrency policy value, use: allow/forbid/replace")
+	// ErrJobPaused is returned when trying to run a job that is paused.
+	ErrJobPaused = errors.New("The job is paused")
+	// ErrWrongTimezone is returned when Timezone is not a valid IANA zone name.
+	ErrWrongTimezone = errors.New("Unknown timezone, use a valid IANA time zone name")
+	// ErrJobRunning is returned by Trigger when the job is already running,
+	// concurrency is forbid, and the trigger was not forced.
+	ErrJobRunning = errors.New("The job is already running and concurrency is set to forbid")
+	// ErrExecutionTimedOut is passed to AfterRunWithError when enforceDeadline
+	// kills an execution for exceeding ActiveDeadlineSeconds.
+	ErrExecutionTimedOut = errors.New("Execution exceeded its ActiveDeadlineSeconds and was killed")
 )
 
 // Job descibes a scheduled Job.
@@ -79,6 +114,23 @@ type Job struct {
 	// Is this job disabled?
 	Disabled bool `json:"disabled"`
 
+	// Is this job paused? A paused job is skipped by the scheduler until
+	// explicitly resumed, either by an operator or by Resume().
+	Paused bool `json:"paused"`
+
+	// MaxConsecutiveErrors is the number of consecutive failed executions
+	// allowed before the job is automatically paused. Zero disables the policy.
+	MaxConsecutiveErrors uint `json:"max_consecutive_errors"`
+
+	// PauseOnFailure enables the auto-pause-on-consecutive-failures policy.
+	PauseOnFailure bool `json:"pause_on_failure"`
+
+	// ConsecutiveErrors counts the job's current run of back-to-back failed
+	// executions. RecordResult increments it on failure and resets it on
+	// success; pauseOnConsecutiveErrors compares it against
+	// MaxConsecutiveErrors.
+	ConsecutiveErrors uint `json:"consecutive_errors"`
+
 	// Tags of the target servers to run this job against.
 	Tags map[string]string `json:"tags"`
 
@@ -88,6 +140,22 @@ type Job struct {
 	// Number of times to retry a job that failed an execution.
 	Retries uint `json:"retries"`
 
+	// RetryStrategy picks how delays between retries grow: "fixed" or
+	// "exponential". Empty behaves as "fixed".
+	RetryStrategy string `json:"retry_strategy"`
+
+	// RetryBackoff is the base delay before the first retry.
+	RetryBackoff time.Duration `json:"retry_backoff"`
+
+	// RetryBackoffMax caps the delay between retries regardless of strategy.
+	// The cap is applied before jitter, so a jittered delay can exceed it by
+	// up to RetryBackoffJitter of the capped delay.
+	RetryBackoffMax time.Duration `json:"retry_backoff_max"`
+
+	// RetryBackoffJitter is the fraction (0.0-1.0) of the computed delay to
+	// randomize, to avoid a thundering herd of synchronized retries.
+	RetryBackoffJitter float64 `json:"retry_backoff_jitter"`
+
 	running sync.Mutex
 
 	// Jobs that are dependent upon this one will be run after this job runs.
@@ -103,6 +171,38 @@ type Job struct {
 
 	// Concurrency policy for this job (allow, forbid)
 	Concurrency string `json:"concurrency"`
+
+	// Timezone is the IANA name (e.g. "Europe/Berlin") the cron Schedule is
+	// evaluated in. Empty falls back to UTC.
+	Timezone string `json:"timezone"`
+
+	// ActiveDeadlineSeconds bounds how long a single execution may run
+	// before it's killed and marked timed out. Nil means unbounded.
+	ActiveDeadlineSeconds *int64 `json:"active_deadline_seconds"`
+
+	// location caches the *time.Location resolved from Timezone so it's
+	// loaded only once at job-load time.
+	location *time.Location
+
+	// EventListeners are optional callbacks invoked around this job's
+	// lifecycle. Not persisted; set in-process by plugins/processors.
+	EventListeners *JobEventListeners `json:"-"`
+}
+
+// JobEventListeners holds optional callbacks fired around a job's execution
+// lifecycle, so processors or plugins can implement metrics, alerting or
+// chained workflows without forking the scheduler core.
+type JobEventListeners struct {
+	// BeforeRun is called right before an execution is dispatched.
+	BeforeRun func(jobName string, execution *Execution, err error)
+	// AfterRun is called after an execution finishes, regardless of outcome.
+	AfterRun func(jobName string, execution *Execution, err error)
+	// AfterRunWithError is called after an execution finishes with an error.
+	AfterRunWithError func(jobName string, execution *Execution, err error)
+	// OnLockError is called when acquiring the distributed job lock fails.
+	OnLockError func(jobName string, execution *Execution, err error)
+	// OnRetry is called before each retry attempt.
+	OnRetry func(jobName string, execution *Execution, err error)
 }
 
 // Run the job
@@ -112,6 +212,18 @@ func (j *Job) Run() {
 
 	// Maybe we are testing or it's disabled
 	if j.Agent != nil && j.Disabled == false {
+		// Resolve the job's *time.Location (cached on the job after the
+		// first call) and skip this run if Timezone is not a valid zone.
+		if _, err := j.GetLocation(); err != nil {
+			log.WithError(err).WithFields(logrus.Fields{
+				"job":      j.Name,
+				"timezone": j.Timezone,
+			}).Error("scheduler: Skipping execution, invalid job timezone")
+			return
+		}
+
+		j.pauseOnConsecutiveErrors()
+
 		// Check if it's runnable
 		if j.isRunnable() {
 			log.WithFields(logrus.Fields{
@@ -123,14 +235,173 @@ func (j *Job) Run() {
 
 			// Simple execution wrapper
 			ex := NewExecution(j.Name)
+			ex.Command = j.Command
+			ex.EnvironmentVariables = j.EnvironmentVariables
+			ex.Tags = j.Tags
+			j.fireBeforeRun(ex)
 			j.Agent.RunQuery(ex)
+			j.enforceDeadline(ex)
 		}
 	}
 }
 
+// TriggerOptions overrides a job's normal configuration for a single
+// Trigger invocation.
+type TriggerOptions struct {
+	// EnvironmentVariables, when non-nil, replaces the job's EnvironmentVariables for this run.
+	EnvironmentVariables []string
+	// Command, when non-empty, replaces the job's Command for this run.
+	Command string
+	// Tags, when non-nil, replaces the job's target Tags for this run.
+	Tags map[string]string
+	// Payload is made available to the executor for this run.
+	Payload []byte
+	// Force bypasses ConcurrencyForbid. Paused jobs still refuse to trigger.
+	Force bool
+}
+
+// Trigger forces an immediate run of the job, independent of its cron
+// Schedule. Unless Force is set, it refuses with ErrJobRunning when
+// Concurrency is forbid and the job is running; a paused job always refuses.
+// Unlike the scheduler-driven Run, Trigger always reports why it could not run.
+func (j *Job) Trigger(overrides *TriggerOptions) (*Execution, error) {
+	if j.Agent == nil {
+		return nil, ErrNoAgent
+	}
+
+	if j.Paused {
+		return nil, ErrJobPaused
+	}
+
+	if overrides == nil {
+		overrides = &TriggerOptions{}
+	}
+
+	if !overrides.Force && j.Concurrency == ConcurrencyForbid && j.Status() == Running {
+		return nil, ErrJobRunning
+	}
+
+	ex := NewExecution(j.Name)
+	ex.Command = j.Command
+	ex.EnvironmentVariables = j.EnvironmentVariables
+	ex.Tags = j.Tags
+	ex.Payload = overrides.Payload
+
+	if overrides.EnvironmentVariables != nil {
+		ex.EnvironmentVariables = overrides.EnvironmentVariables
+	}
+	if overrides.Command != "" {
+		ex.Command = overrides.Command
+	}
+	if overrides.Tags != nil {
+		ex.Tags = overrides.Tags
+	}
+
+	j.fireBeforeRun(ex)
+	j.Agent.RunQuery(ex)
+	j.enforceDeadline(ex)
+
+	return ex, nil
+}
+
+// enforceDeadline starts a watchdog goroutine that kills ex if it hasn't
+// finished within ActiveDeadlineSeconds, marking it as timed out. A nil
+// ActiveDeadlineSeconds leaves the execution unbounded.
+func (j *Job) enforceDeadline(ex *Execution) {
+	if j.ActiveDeadlineSeconds == nil || j.Agent == nil {
+		return
+	}
+
+	deadline := time.Duration(*j.ActiveDeadlineSeconds) * time.Second
+
+	go func() {
+		timer := time.NewTimer(deadline)
+		defer timer.Stop()
+		<-timer.C
+
+		execs, err := j.Agent.store.GetLastExecutionGroup(j.Name)
+		if err != nil {
+			return
+		}
+
+		for _, current := range execs {
+			if current.Id != ex.Id || !current.FinishedAt.IsZero() {
+				continue
+			}
+
+			log.WithFields(logrus.Fields{
+				"job":       j.Name,
+				"execution": current.Id,
+				"deadline":  deadline,
+			}).Warn("scheduler: Execution exceeded ActiveDeadlineSeconds, killing")
+
+			current.Success = false
+			current.TimedOut = true
+			current.FinishedAt = time.Now()
+
+			if err := j.Agent.RunKillQuery(current); err != nil {
+				log.WithError(err).WithField("job", j.Name).Error("scheduler: Error killing timed out execution")
+			}
+
+			j.fireAfterRun(current, ErrExecutionTimedOut)
+		}
+	}()
+}
+
 // Friendly format a job
 func (j *Job) String() string {
-	return fmt.Sprintf("\"Job: %s, scheduled at: %s, tags:%v\"", j.Name, j.Schedule, j.Tags)
+	return fmt.Sprintf("\"Job: %s, scheduled at: %s, timezone: %s, tags:%v\"", j.Name, j.Schedule, j.GetTimezone(), j.Tags)
+}
+
+// GetTimezone returns the configured Timezone, or "UTC" when unset.
+func (j *Job) GetTimezone() string {
+	if j.Timezone == "" {
+		return "UTC"
+	}
+	return j.Timezone
+}
+
+// GetLocation resolves Timezone to a *time.Location, caching it on the job
+// so it's only parsed once. An empty Timezone resolves to time.UTC.
+func (j *Job) GetLocation() (*time.Location, error) {
+	if j.location != nil {
+		return j.location, nil
+	}
+
+	if j.Timezone == "" {
+		j.location = time.UTC
+		return j.location, nil
+	}
+
+	loc, err := time.LoadLocation(j.Timezone)
+	if err != nil {
+		return nil, ErrWrongTimezone
+	}
+
+	j.location = loc
+	return j.location, nil
+}
+
+// ScheduleSpec returns the cron spec the scheduler should parse to build
+// this job's cron.Schedule. When Timezone is set it's prefixed with a
+// CRON_TZ directive, so the entry's ticks are computed in the job's local
+// zone instead of the agent's; cron.ParseStandard/robfig's parser honors
+// that prefix natively. Callers building the job's cron.Schedule should
+// use this instead of the raw Schedule field.
+func (j *Job) ScheduleSpec() string {
+	if j.Timezone == "" {
+		return j.Schedule
+	}
+	return fmt.Sprintf("CRON_TZ=%s %s", j.Timezone, j.Schedule)
+}
+
+// Validate checks that the job's Timezone, if set, is a loadable IANA zone
+// name. It should be called by the job store before persisting a job, so a
+// bad Timezone is rejected at save time instead of silently falling back
+// to UTC when the schedule is later evaluated.
+func (j *Job) Validate() error {
+	_, err := j.GetLocation()
+	return err
 }
 
 // Status returns the status of a job whether it's running, succeded or failed
@@ -145,6 +416,14 @@ func (j *Job) Status() int {
 	failed := 0
 	for _, ex := range execs {
 		if ex.FinishedAt.IsZero() {
+			// A still-running execution past its ActiveDeadlineSeconds is
+			// stuck, not running: don't let it wedge the job status forever.
+			if j.ActiveDeadlineSeconds != nil && !ex.StartedAt.IsZero() {
+				deadline := time.Duration(*j.ActiveDeadlineSeconds) * time.Second
+				if time.Since(ex.StartedAt) > deadline {
+					continue
+				}
+			}
 			return Running
 		}
 	}
@@ -207,12 +486,14 @@ func (j *Job) Lock() error {
 	// TODO: LockOptions empty is a temporary fix until https://github.com/docker/libkv/pull/99 is fixed
 	l, err := j.Agent.store.Client.NewLock(lockKey, &store.LockOptions{RenewLock: make(chan (struct{}))})
 	if err != nil {
+		j.fireOnLockError(err)
 		return err
 	}
 	j.lock = l
 
 	_, err = j.lock.Lock(nil)
 	if err != nil {
+		j.fireOnLockError(err)
 		return err
 	}
 
@@ -233,7 +514,111 @@ func (j *Job) Unlock() error {
 	return nil
 }
 
+// Pause marks the job as paused so the scheduler skips it until Resume is called.
+func (j *Job) Pause() error {
+	j.Paused = true
+
+	if j.Agent == nil {
+		return nil
+	}
+
+	return j.Agent.store.SetJob(j, true)
+}
+
+// Resume clears the paused state, allowing the scheduler to run the job again.
+func (j *Job) Resume() error {
+	j.Paused = false
+
+	if j.Agent == nil {
+		return nil
+	}
+
+	return j.Agent.store.SetJob(j, true)
+}
+
+// RecordResult records the outcome of ex (err is nil on success), updates
+// ConsecutiveErrors and auto-pauses the job via pauseOnConsecutiveErrors,
+// then fires AfterRun/AfterRunWithError for ex.
+func (j *Job) RecordResult(ex *Execution, err error) {
+	if err == nil {
+		j.ConsecutiveErrors = 0
+	} else {
+		j.ConsecutiveErrors++
+		j.pauseOnConsecutiveErrors()
+	}
+
+	j.fireAfterRun(ex, err)
+}
+
+// pauseOnConsecutiveErrors auto-pauses the job once ConsecutiveErrors has
+// reached MaxConsecutiveErrors, requiring an explicit Resume.
+func (j *Job) pauseOnConsecutiveErrors() {
+	if !j.PauseOnFailure || j.MaxConsecutiveErrors == 0 || j.Paused {
+		return
+	}
+
+	if j.ConsecutiveErrors < j.MaxConsecutiveErrors {
+		return
+	}
+
+	log.WithFields(logrus.Fields{
+		"job":                    j.Name,
+		"consecutive_errors":     j.ConsecutiveErrors,
+		"max_consecutive_errors": j.MaxConsecutiveErrors,
+	}).Warn("scheduler: Auto-pausing job after consecutive failures")
+
+	if err := j.Pause(); err != nil {
+		log.WithError(err).WithField("job", j.Name).Error("scheduler: Error pausing job")
+		return
+	}
+
+	if j.Agent != nil {
+		j.Agent.serf.UserEvent(QueryJobPaused, []byte(j.Name), false)
+	}
+}
+
+// fireBeforeRun invokes the BeforeRun listener, if any.
+func (j *Job) fireBeforeRun(ex *Execution) {
+	if j.EventListeners != nil && j.EventListeners.BeforeRun != nil {
+		j.EventListeners.BeforeRun(j.Name, ex, nil)
+	}
+}
+
+// fireAfterRun invokes the AfterRun listener, and AfterRunWithError too when err is non-nil.
+func (j *Job) fireAfterRun(ex *Execution, err error) {
+	if j.EventListeners == nil {
+		return
+	}
+	if j.EventListeners.AfterRun != nil {
+		j.EventListeners.AfterRun(j.Name, ex, err)
+	}
+	if err != nil && j.EventListeners.AfterRunWithError != nil {
+		j.EventListeners.AfterRunWithError(j.Name, ex, err)
+	}
+}
+
+// fireOnLockError invokes the OnLockError listener, if any.
+func (j *Job) fireOnLockError(err error) {
+	if j.EventListeners != nil && j.EventListeners.OnLockError != nil {
+		j.EventListeners.OnLockError(j.Name, nil, err)
+	}
+}
+
+// fireOnRetry invokes the OnRetry listener, if any.
+func (j *Job) fireOnRetry(ex *Execution, err error) {
+	if j.EventListeners != nil && j.EventListeners.OnRetry != nil {
+		j.EventListeners.OnRetry(j.Name, ex, err)
+	}
+}
+
 func (j *Job) isRunnable() bool {
+	if j.Paused {
+		log.WithFields(logrus.Fields{
+			"job": j.Name,
+		}).Debug("scheduler: Skipping execution, job is paused")
+		return false
+	}
+
 	status := j.Status()
 
 	if status == Running {
@@ -246,8 +631,146 @@ func (j *Job) isRunnable() bool {
 				"job_status":  status,
 			}).Debug("scheduler: Skipping execution")
 			return false
+		} else if j.Concurrency == ConcurrencyReplace {
+			log.WithFields(logrus.Fields{
+				"job":         j.Name,
+				"concurrency": j.Concurrency,
+				"job_status":  status,
+			}).Debug("scheduler: Killing running execution to replace it")
+
+			if err := j.Kill(); err != nil {
+				log.WithError(err).WithField("job", j.Name).Error("scheduler: Error killing running execution")
+			} else {
+				j.waitForReplace()
+			}
 		}
 	}
 
 	return true
 }
+
+// waitForReplace blocks until every execution isRunnable just asked Kill to
+// cancel has a FinishedAt set, or until replaceKillTimeout elapses,
+// whichever comes first, so the replacement execution doesn't start
+// alongside the one it's meant to replace.
+func (j *Job) waitForReplace() {
+	deadline := time.Now().Add(replaceKillTimeout)
+
+	for time.Now().Before(deadline) {
+		execs, err := j.Agent.store.GetLastExecutionGroup(j.Name)
+		if err != nil {
+			return
+		}
+
+		finished := true
+		for _, ex := range execs {
+			if ex.FinishedAt.IsZero() {
+				finished = false
+				break
+			}
+		}
+		if finished {
+			return
+		}
+
+		time.Sleep(replaceKillPollInterval)
+	}
+
+	log.WithFields(logrus.Fields{
+		"job":     j.Name,
+		"timeout": replaceKillTimeout,
+	}).Warn("scheduler: Timed out waiting for killed execution to finish, starting replacement anyway")
+}
+
+// Kill cancels every still-running execution of this job, used by the
+// ConcurrencyReplace policy to make way for a newly triggered run.
+func (j *Job) Kill() error {
+	if j.Agent == nil {
+		return ErrNoAgent
+	}
+
+	execs, err := j.Agent.store.GetLastExecutionGroup(j.Name)
+	if err != nil {
+		return err
+	}
+
+	for _, ex := range execs {
+		if !ex.FinishedAt.IsZero() {
+			continue
+		}
+
+		if err := j.Agent.RunKillQuery(ex); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// retryBackoff computes the delay to wait before the given retry attempt
+// (0-indexed): the RetryStrategy delay, capped at RetryBackoffMax, then
+// randomized by up to ±RetryBackoffJitter of that capped delay.
+func (j *Job) retryBackoff(attempt uint) time.Duration {
+	delay := j.RetryBackoff
+
+	if j.RetryStrategy == RetryStrategyExponential {
+		delay = j.RetryBackoff * time.Duration(math.Pow(2, float64(attempt)))
+	}
+
+	if j.RetryBackoffMax > 0 && delay > j.RetryBackoffMax {
+		delay = j.RetryBackoffMax
+	}
+
+	if j.RetryBackoffJitter > 0 && delay > 0 {
+		jitter := float64(delay) * j.RetryBackoffJitter
+		delay = delay - time.Duration(jitter) + time.Duration(rand.Float64()*2*jitter)
+	}
+
+	if delay < 0 {
+		delay = 0
+	}
+
+	return delay
+}
+
+// waitRetry blocks for the backoff delay of the given attempt, firing
+// OnRetry for ex/cause just before returning, or returns early with
+// ctx.Err() (skipping OnRetry) if ctx is canceled first (e.g. the job was
+// paused, deleted or killed while waiting to retry).
+func (j *Job) waitRetry(ctx context.Context, attempt uint, ex *Execution, cause error) error {
+	delay := j.retryBackoff(attempt)
+	if delay == 0 {
+		j.fireOnRetry(ex, cause)
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		j.fireOnRetry(ex, cause)
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Retry waits out the backoff delay for attempt (aborting early if ctx is
+// canceled) and then dispatches ex as the next RunQuery. cause is the error
+// that made ex eligible for retry, reported to the OnRetry listener.
+func (j *Job) Retry(ctx context.Context, attempt uint, ex *Execution, cause error) error {
+	if j.Agent == nil {
+		return ErrNoAgent
+	}
+
+	if err := j.waitRetry(ctx, attempt, ex, cause); err != nil {
+		return err
+	}
+
+	j.fireBeforeRun(ex)
+	j.Agent.RunQuery(ex)
+	j.enforceDeadline(ex)
+
+	return nil
+}
@@ -0,0 +1,230 @@
+package dkron
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJobRetryBackoffFixed(t *testing.T) {
+	j := &Job{
+		RetryStrategy: RetryStrategyFixed,
+		RetryBackoff:  100 * time.Millisecond,
+	}
+
+	assert.Equal(t, 100*time.Millisecond, j.retryBackoff(0))
+	assert.Equal(t, 100*time.Millisecond, j.retryBackoff(3))
+}
+
+func TestJobRetryBackoffExponentialGrowth(t *testing.T) {
+	j := &Job{
+		RetryStrategy: RetryStrategyExponential,
+		RetryBackoff:  100 * time.Millisecond,
+	}
+
+	assert.Equal(t, 100*time.Millisecond, j.retryBackoff(0))
+	assert.Equal(t, 200*time.Millisecond, j.retryBackoff(1))
+	assert.Equal(t, 400*time.Millisecond, j.retryBackoff(2))
+	assert.Equal(t, 800*time.Millisecond, j.retryBackoff(3))
+}
+
+func TestJobRetryBackoffMaxCap(t *testing.T) {
+	j := &Job{
+		RetryStrategy:   RetryStrategyExponential,
+		RetryBackoff:    100 * time.Millisecond,
+		RetryBackoffMax: 300 * time.Millisecond,
+	}
+
+	assert.Equal(t, 300*time.Millisecond, j.retryBackoff(5))
+}
+
+func TestJobRetryBackoffJitterBounds(t *testing.T) {
+	j := &Job{
+		RetryStrategy:      RetryStrategyFixed,
+		RetryBackoff:       100 * time.Millisecond,
+		RetryBackoffJitter: 0.5,
+	}
+
+	min := 50 * time.Millisecond
+	max := 150 * time.Millisecond
+	for i := 0; i < 100; i++ {
+		delay := j.retryBackoff(0)
+		assert.True(t, delay >= min && delay <= max, "delay %s out of bounds [%s, %s]", delay, min, max)
+	}
+}
+
+func TestJobWaitRetryCancellation(t *testing.T) {
+	var gotEx *Execution
+	j := &Job{
+		RetryStrategy: RetryStrategyFixed,
+		RetryBackoff:  time.Minute,
+		EventListeners: &JobEventListeners{
+			OnRetry: func(jobName string, ex *Execution, err error) { gotEx = ex },
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := j.waitRetry(ctx, 0, &Execution{Id: "1"}, errors.New("boom"))
+	assert.Equal(t, context.Canceled, err)
+	assert.Nil(t, gotEx, "OnRetry must not fire when the wait is canceled")
+}
+
+func TestJobScheduleSpecNoTimezone(t *testing.T) {
+	j := &Job{Schedule: "* * * * *"}
+	assert.Equal(t, "* * * * *", j.ScheduleSpec())
+}
+
+func TestJobScheduleSpecWithTimezone(t *testing.T) {
+	j := &Job{Schedule: "* * * * *", Timezone: "Europe/Berlin"}
+	assert.Equal(t, "CRON_TZ=Europe/Berlin * * * * *", j.ScheduleSpec())
+}
+
+func TestJobValidateRejectsUnknownTimezone(t *testing.T) {
+	j := &Job{Timezone: "Not/AZone"}
+	assert.Equal(t, ErrWrongTimezone, j.Validate())
+}
+
+func TestJobValidateAcceptsKnownTimezone(t *testing.T) {
+	j := &Job{Timezone: "Europe/Berlin"}
+	assert.NoError(t, j.Validate())
+}
+
+func TestJobRecordResultAutoPause(t *testing.T) {
+	j := &Job{
+		PauseOnFailure:       true,
+		MaxConsecutiveErrors: 3,
+	}
+	boom := errors.New("boom")
+
+	j.RecordResult(&Execution{}, boom)
+	j.RecordResult(&Execution{}, boom)
+	assert.False(t, j.Paused)
+	assert.Equal(t, uint(2), j.ConsecutiveErrors)
+
+	j.RecordResult(&Execution{}, boom)
+	assert.True(t, j.Paused)
+	assert.Equal(t, uint(3), j.ConsecutiveErrors)
+}
+
+func TestJobRecordResultResetsOnSuccess(t *testing.T) {
+	j := &Job{
+		PauseOnFailure:       true,
+		MaxConsecutiveErrors: 2,
+	}
+	boom := errors.New("boom")
+
+	j.RecordResult(&Execution{}, boom)
+	j.RecordResult(&Execution{}, nil)
+	j.RecordResult(&Execution{}, boom)
+	assert.False(t, j.Paused)
+	assert.Equal(t, uint(1), j.ConsecutiveErrors)
+}
+
+func TestJobRecordResultFiresAfterRun(t *testing.T) {
+	var gotAfterRun, gotAfterRunWithError *Execution
+	j := &Job{
+		EventListeners: &JobEventListeners{
+			AfterRun:          func(jobName string, ex *Execution, err error) { gotAfterRun = ex },
+			AfterRunWithError: func(jobName string, ex *Execution, err error) { gotAfterRunWithError = ex },
+		},
+	}
+
+	ex := &Execution{Id: "1"}
+	j.RecordResult(ex, nil)
+	assert.Equal(t, ex, gotAfterRun)
+	assert.Nil(t, gotAfterRunWithError)
+
+	ex2 := &Execution{Id: "2"}
+	boom := errors.New("boom")
+	j.RecordResult(ex2, boom)
+	assert.Equal(t, ex2, gotAfterRun)
+	assert.Equal(t, ex2, gotAfterRunWithError)
+}
+
+func TestJobFireBeforeRun(t *testing.T) {
+	var got *Execution
+	j := &Job{
+		EventListeners: &JobEventListeners{
+			BeforeRun: func(jobName string, ex *Execution, err error) { got = ex },
+		},
+	}
+
+	ex := &Execution{Id: "1"}
+	j.fireBeforeRun(ex)
+	assert.Equal(t, ex, got)
+}
+
+func TestJobFireOnLockError(t *testing.T) {
+	var gotErr error
+	j := &Job{
+		EventListeners: &JobEventListeners{
+			OnLockError: func(jobName string, ex *Execution, err error) { gotErr = err },
+		},
+	}
+
+	boom := errors.New("boom")
+	j.fireOnLockError(boom)
+	assert.Equal(t, boom, gotErr)
+}
+
+func TestJobFireOnRetry(t *testing.T) {
+	var gotEx *Execution
+	var gotErr error
+	j := &Job{
+		EventListeners: &JobEventListeners{
+			OnRetry: func(jobName string, ex *Execution, err error) { gotEx, gotErr = ex, err },
+		},
+	}
+
+	ex := &Execution{Id: "1"}
+	boom := errors.New("boom")
+	j.fireOnRetry(ex, boom)
+	assert.Equal(t, ex, gotEx)
+	assert.Equal(t, boom, gotErr)
+}
+
+func TestJobFireListenersNoopWithoutListeners(t *testing.T) {
+	j := &Job{}
+
+	assert.NotPanics(t, func() {
+		j.fireBeforeRun(&Execution{})
+		j.fireAfterRun(&Execution{}, errors.New("boom"))
+		j.fireOnLockError(errors.New("boom"))
+		j.fireOnRetry(&Execution{}, errors.New("boom"))
+	})
+}
+
+func TestJobWaitRetryCompletes(t *testing.T) {
+	j := &Job{
+		RetryStrategy: RetryStrategyFixed,
+		RetryBackoff:  10 * time.Millisecond,
+	}
+
+	err := j.waitRetry(context.Background(), 0, &Execution{Id: "1"}, errors.New("boom"))
+	assert.NoError(t, err)
+}
+
+func TestJobWaitRetryFiresOnRetryWithExecutionAndCause(t *testing.T) {
+	var gotEx *Execution
+	var gotErr error
+	j := &Job{
+		RetryStrategy: RetryStrategyFixed,
+		RetryBackoff:  0,
+		EventListeners: &JobEventListeners{
+			OnRetry: func(jobName string, ex *Execution, err error) { gotEx, gotErr = ex, err },
+		},
+	}
+
+	ex := &Execution{Id: "1"}
+	cause := errors.New("boom")
+
+	err := j.waitRetry(context.Background(), 0, ex, cause)
+	assert.NoError(t, err)
+	assert.Equal(t, ex, gotEx)
+	assert.Equal(t, cause, gotErr)
+}